@@ -0,0 +1,372 @@
+// Package lte exposes the SIM7600's data-mode sockets (+CIPOPEN and
+// friends) as standard net.Conn/net.PacketConn values, so existing Go
+// networking code can run over cellular without writing any AT commands.
+package lte
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Potsdam-Sensors/waveshare-lte-hat-pi/wavesharecomm"
+)
+
+// maxLinks is the number of simultaneous +CIPOPEN sockets the SIM7600
+// supports.
+const maxLinks = 10
+
+const (
+	cipopenTimeout  = 30 * time.Second
+	cipsendTimeout  = 10 * time.Second
+	cipcloseTimeout = 10 * time.Second
+
+	// rxChunkSize is how many bytes are requested per AT+CIPRXGET=2 read.
+	rxChunkSize = 1024
+)
+
+// Dialer opens TCP/UDP sockets through a single SIM7600 modem. It tracks
+// which of the modem's 10 connection IDs are in use and multiplexes them
+// safely across goroutines; all AT traffic is further serialized by the
+// underlying Modem.
+type Dialer struct {
+	modem        *wavesharecomm.Modem
+	pdpContextID int
+
+	mu    sync.Mutex
+	links [maxLinks]bool
+}
+
+// NewDialer activates PDP context `pdpContextID` (as configured with
+// +CGDCONT) and returns a Dialer that opens sockets against it.
+func NewDialer(modem *wavesharecomm.Modem, pdpContextID int) (*Dialer, error) {
+	_, ok, err := modem.ExecuteCommand(fmt.Sprintf("+CGACT=1,%d", pdpContextID), cipopenTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("error activating PDP context %d: %w", pdpContextID, err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("modem rejected activation of PDP context %d", pdpContextID)
+	}
+	return &Dialer{modem: modem, pdpContextID: pdpContextID}, nil
+}
+
+// Dial opens a socket to addr ("host:port") over the given network
+// ("tcp"/"tcp4"/"tcp6" or "udp"/"udp4"/"udp6") and returns it as a
+// net.Conn. The returned value also implements net.PacketConn.
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext is Dial, but returns ctx.Err() without opening a socket if
+// `ctx` is already done. Once +CIPOPEN has been sent, `ctx` is no longer
+// consulted: the handshake runs until the modem answers or cipopenTimeout
+// elapses.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	simType, err := socketType(network)
+	if err != nil {
+		return nil, err
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in address %q: %w", addr, err)
+	}
+
+	linkID, err := d.allocLink()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		d.freeLink(linkID)
+		return nil, ctx.Err()
+	default:
+	}
+
+	cmd := fmt.Sprintf("+CIPOPEN=%d,\"%s\",\"%s\",%d", linkID, simType, host, port)
+	_, ok, err := d.modem.ExecuteCommand(cmd, cipopenTimeout)
+	if err != nil {
+		d.freeLink(linkID)
+		return nil, fmt.Errorf("error opening %s socket to %s: %w", simType, addr, err)
+	}
+	if !ok {
+		d.freeLink(linkID)
+		return nil, fmt.Errorf("modem rejected +CIPOPEN to %s", addr)
+	}
+
+	c := newConn(d, linkID, network, addr)
+	return c, nil
+}
+
+func socketType(network string) (string, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		return "TCP", nil
+	case "udp", "udp4", "udp6":
+		return "UDP", nil
+	default:
+		return "", fmt.Errorf("unsupported network %q", network)
+	}
+}
+
+func (d *Dialer) allocLink() (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, used := range d.links {
+		if !used {
+			d.links[i] = true
+			return i, nil
+		}
+	}
+	return -1, errors.New("no free SIM7600 connection IDs (10 already in use)")
+}
+
+func (d *Dialer) freeLink(id int) {
+	d.mu.Lock()
+	d.links[id] = false
+	d.mu.Unlock()
+}
+
+// addr is a minimal net.Addr for a modem socket.
+type addr struct {
+	network string
+	address string
+}
+
+func (a addr) Network() string { return a.network }
+func (a addr) String() string  { return a.address }
+
+// unsolicitedDataPrefix is the +CIPRXGET: URC the SIM7600 sends when new
+// data arrives on a link that has not yet been read.
+const unsolicitedDataPrefix = "+CIPRXGET:"
+
+// Conn is a socket opened via Dialer.Dial. It implements both net.Conn
+// and net.PacketConn; for UDP, ReadFrom/WriteTo are used, while Read/Write
+// address the single peer passed to Dial.
+type Conn struct {
+	dialer     *Dialer
+	linkID     int
+	localAddr  net.Addr
+	remoteAddr net.Addr
+
+	rxCh       chan []byte
+	rxLeftover []byte
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	readDeadlineMu sync.Mutex
+	readDeadline   time.Time
+}
+
+func newConn(d *Dialer, linkID int, network, remoteAddrStr string) *Conn {
+	c := &Conn{
+		dialer:     d,
+		linkID:     linkID,
+		localAddr:  addr{network: network, address: "lte"},
+		remoteAddr: addr{network: network, address: remoteAddrStr},
+		rxCh:       make(chan []byte, 16),
+		closed:     make(chan struct{}),
+	}
+	go c.pollRx()
+	return c
+}
+
+// pollRx subscribes to this link's data-available URCs and fetches data
+// with AT+CIPRXGET=2 as it arrives, delivering each chunk on rxCh.
+func (c *Conn) pollRx() {
+	urcCh := c.dialer.modem.Subscribe(unsolicitedDataPrefix)
+	defer close(c.rxCh)
+	for {
+		select {
+		case <-c.closed:
+			return
+		case urc, ok := <-urcCh:
+			if !ok {
+				return
+			}
+			if len(urc.Lines) == 0 {
+				continue
+			}
+			fields := strings.Split(strings.TrimPrefix(string(urc.Lines[0]), unsolicitedDataPrefix), ",")
+			fields = trimFields(fields)
+			if len(fields) < 2 || fields[0] != "1" {
+				continue // only "+CIPRXGET: 1,<id>" indicates new data
+			}
+			linkID, err := strconv.Atoi(fields[1])
+			if err != nil || linkID != c.linkID {
+				continue
+			}
+			c.drainRx()
+		}
+	}
+}
+
+// drainRx issues AT+CIPRXGET=2 reads until the modem reports no more
+// unread bytes for this link.
+func (c *Conn) drainRx() {
+	for {
+		data, more, err := c.readChunk()
+		if err != nil {
+			return
+		}
+		if len(data) > 0 {
+			select {
+			case c.rxCh <- data:
+			case <-c.closed:
+				return
+			}
+		}
+		if !more {
+			return
+		}
+	}
+}
+
+// readChunk performs one AT+CIPRXGET=2,<id>,<len> read and returns its
+// payload and whether more unread data remains.
+//
+// The payload is raw, unframed binary data, so it is fetched via
+// ExecuteBinaryCommand rather than ExecuteCommand: the usual line-oriented
+// splitting would corrupt any CR/LF byte the payload happens to contain.
+func (c *Conn) readChunk() (data []byte, more bool, err error) {
+	cmd := fmt.Sprintf("+CIPRXGET=2,%d,%d", c.linkID, rxChunkSize)
+
+	var cnfLen int
+	rawDataLength := func(line []byte) int {
+		if !bytes.HasPrefix(line, []byte(unsolicitedDataPrefix)) {
+			return 0
+		}
+		header := strings.TrimPrefix(string(line), unsolicitedDataPrefix)
+		fields := trimFields(strings.Split(header, ","))
+		if len(fields) < 4 {
+			return 0
+		}
+		cnfLen, _ = strconv.Atoi(fields[3])
+		return cnfLen
+	}
+
+	lines, ok, err := c.dialer.modem.ExecuteBinaryCommand(cmd, rawDataLength, cipsendTimeout)
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		return nil, false, fmt.Errorf("modem rejected +CIPRXGET=2 on link %d", c.linkID)
+	}
+	if cnfLen == 0 {
+		return nil, false, nil
+	}
+	if len(lines) < 2 {
+		return nil, false, fmt.Errorf("+CIPRXGET: header announced %d bytes but none followed", cnfLen)
+	}
+	return lines[1], cnfLen == rxChunkSize, nil
+}
+
+func trimFields(fields []string) []string {
+	for i, f := range fields {
+		fields[i] = strings.TrimSpace(f)
+	}
+	return fields
+}
+
+// Read implements net.Conn.
+func (c *Conn) Read(b []byte) (int, error) {
+	if len(c.rxLeftover) > 0 {
+		n := copy(b, c.rxLeftover)
+		c.rxLeftover = c.rxLeftover[n:]
+		return n, nil
+	}
+
+	var timeoutCh <-chan time.Time
+	c.readDeadlineMu.Lock()
+	deadline := c.readDeadline
+	c.readDeadlineMu.Unlock()
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	select {
+	case data, ok := <-c.rxCh:
+		if !ok {
+			return 0, net.ErrClosed
+		}
+		n := copy(b, data)
+		if n < len(data) {
+			c.rxLeftover = data[n:]
+		}
+		return n, nil
+	case <-timeoutCh:
+		return 0, fmt.Errorf("read on link %d timed out", c.linkID)
+	case <-c.closed:
+		return 0, net.ErrClosed
+	}
+}
+
+// Write implements net.Conn. The payload is chunked and paced according
+// to the underlying Modem's WritePolicy (see wavesharecomm.WritePaced),
+// which by default caps windows at the SIM7600's ~1460-byte per-send TCP
+// limit and backs off when +CIPSEND reports a partial confirm.
+func (c *Conn) Write(b []byte) (int, error) {
+	cmdFormat := func(n int) string { return fmt.Sprintf("+CIPSEND=%d,%d", c.linkID, n) }
+	if err := c.dialer.modem.WritePaced(cmdFormat, b, cipsendTimeout); err != nil {
+		return 0, fmt.Errorf("error writing to link %d: %w", c.linkID, err)
+	}
+	return len(b), nil
+}
+
+// Close closes the underlying SIM7600 socket (+CIPCLOSE) and frees its
+// connection ID for reuse.
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		cmd := fmt.Sprintf("+CIPCLOSE=%d", c.linkID)
+		_, _, err = c.dialer.modem.ExecuteCommand(cmd, cipcloseTimeout)
+		c.dialer.freeLink(c.linkID)
+	})
+	return err
+}
+
+func (c *Conn) LocalAddr() net.Addr  { return c.localAddr }
+func (c *Conn) RemoteAddr() net.Addr { return c.remoteAddr }
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return nil
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.readDeadlineMu.Lock()
+	c.readDeadline = t
+	c.readDeadlineMu.Unlock()
+	return nil
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// ReadFrom implements net.PacketConn for UDP sockets opened via Dial: the
+// SIM7600's socket is already bound to a single peer, so every packet
+// comes from RemoteAddr.
+func (c *Conn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.remoteAddr, err
+}
+
+// WriteTo implements net.PacketConn. `addr` is ignored; the SIM7600
+// socket opened via Dial is already bound to a single peer.
+func (c *Conn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	return c.Write(b)
+}
@@ -0,0 +1,477 @@
+package sms
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// encoding identifies which user data scheme a segment was packed with.
+type encoding int
+
+const (
+	encodingGSM7 encoding = iota
+	encodingUCS2
+)
+
+// gsm7BasicChars maps the GSM 03.38 default alphabet's basic (single
+// septet) code points to runes, indexed by code.
+var gsm7BasicChars = []rune(
+	"@£$¥èéùìòÇ\nØø\rÅå" +
+		"Δ_ΦΓΛΩΠΨΣΘΞ\x1bÆæßÉ" +
+		" !\"#¤%&'()*+,-./" +
+		"0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNO" +
+		"PQRSTUVWXYZÄÖÑÜ§" +
+		"¿abcdefghijklmno" +
+		"pqrstuvwxyzäöñüà")
+
+// gsm7ExtChars maps the GSM 03.38 extension table (reached via the 0x1B
+// escape code) to runes, indexed by code.
+var gsm7ExtChars = map[byte]rune{
+	0x0A: '\f',
+	0x14: '^',
+	0x28: '{',
+	0x29: '}',
+	0x2F: '\\',
+	0x3C: '[',
+	0x3D: '~',
+	0x3E: ']',
+	0x40: '|',
+	0x65: '€',
+}
+
+var (
+	gsm7BasicIndex = buildRuneIndex(gsm7BasicChars)
+	gsm7ExtIndex   = buildReverseByteIndex(gsm7ExtChars)
+)
+
+func buildRuneIndex(chars []rune) map[rune]byte {
+	m := make(map[rune]byte, len(chars))
+	for i, r := range chars {
+		if _, exists := m[r]; !exists {
+			m[r] = byte(i)
+		}
+	}
+	return m
+}
+
+func buildReverseByteIndex(m map[byte]rune) map[rune]byte {
+	rev := make(map[rune]byte, len(m))
+	for code, r := range m {
+		rev[r] = code
+	}
+	return rev
+}
+
+// encodeText picks GSM 7-bit packing if every rune in `text` is
+// representable in the default alphabet (plus its extension table), and
+// falls back to UCS-2 otherwise. It returns the packed user data, the
+// encoding used, and the "length" to report in the PDU (septet count for
+// GSM7, octet count for UCS2).
+func encodeText(text string) (userData []byte, enc encoding, length int) {
+	septets, ok := gsm7Septets(text)
+	if ok {
+		return packSeptets(septets, 0), encodingGSM7, len(septets)
+	}
+	ucs2 := encodeUCS2(text)
+	return ucs2, encodingUCS2, len(ucs2)
+}
+
+// gsm7RuneSeptetCost reports how many septets `r` costs in the GSM 03.38
+// default alphabet (1, or 2 for an extension-table character reached via
+// the 0x1B escape), and whether r is representable at all. SplitSegments
+// uses this to segment by septet count rather than rune count.
+func gsm7RuneSeptetCost(r rune) (cost int, ok bool) {
+	if _, found := gsm7BasicIndex[r]; found {
+		return 1, true
+	}
+	if _, found := gsm7ExtIndex[r]; found {
+		return 2, true
+	}
+	return 0, false
+}
+
+// gsm7Septets converts `text` into a sequence of GSM 03.38 septet codes.
+// ok is false if any rune cannot be represented (the caller should use
+// UCS-2 instead).
+func gsm7Septets(text string) (septets []byte, ok bool) {
+	for _, r := range text {
+		if code, found := gsm7BasicIndex[r]; found {
+			septets = append(septets, code)
+			continue
+		}
+		if code, found := gsm7ExtIndex[r]; found {
+			septets = append(septets, 0x1B, code)
+			continue
+		}
+		return nil, false
+	}
+	return septets, true
+}
+
+// packSeptets packs 7-bit septet values into 8-bit octets per 3GPP TS
+// 23.038. `fillBits` zero bits are inserted at the low end of the first
+// octet before the first septet; a preceding UDH uses this to pad the
+// user data out to the next septet boundary.
+func packSeptets(septets []byte, fillBits int) []byte {
+	var packed []byte
+	var buffer uint16
+	bits := uint(fillBits)
+	for _, s := range septets {
+		buffer |= uint16(s) << bits
+		bits += 7
+		for bits >= 8 {
+			packed = append(packed, byte(buffer))
+			buffer >>= 8
+			bits -= 8
+		}
+	}
+	if bits > 0 {
+		packed = append(packed, byte(buffer))
+	}
+	return packed
+}
+
+// unpackSeptets is the inverse of packSeptets: it unpacks `octets` into
+// 7-bit septet values, skipping `fillBits` leading bits.
+func unpackSeptets(octets []byte, fillBits int) []byte {
+	var bits []byte
+	for _, o := range octets {
+		for i := 0; i < 8; i++ {
+			bits = append(bits, (o>>i)&1)
+		}
+	}
+	if fillBits > len(bits) {
+		return nil
+	}
+	bits = bits[fillBits:]
+
+	var septets []byte
+	for len(bits) >= 7 {
+		var v byte
+		for i := 0; i < 7; i++ {
+			v |= bits[i] << i
+		}
+		septets = append(septets, v)
+		bits = bits[7:]
+	}
+	return septets
+}
+
+// decodeGSM7Septets turns septet codes back into text, expanding the
+// extension table escape (0x1B).
+func decodeGSM7Septets(septets []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(septets); i++ {
+		if septets[i] == 0x1B && i+1 < len(septets) {
+			i++
+			if r, found := gsm7ExtChars[septets[i]]; found {
+				sb.WriteRune(r)
+				continue
+			}
+		}
+		if int(septets[i]) < len(gsm7BasicChars) {
+			sb.WriteRune(gsm7BasicChars[septets[i]])
+		}
+	}
+	return sb.String()
+}
+
+func encodeUCS2(text string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(text)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+func decodeUCS2(data []byte) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeSemiOctetNumber swaps each pair of digits into a single byte per
+// 3GPP TS 23.040 (the semi-octet representation used for TP-DA/TP-OA and
+// the SMSC address), padding an odd final digit with 0xF.
+func encodeSemiOctetNumber(digits string) []byte {
+	if len(digits)%2 != 0 {
+		digits += "F"
+	}
+	out := make([]byte, len(digits)/2)
+	for i := 0; i < len(out); i++ {
+		lo := digits[i*2] - '0'
+		hi := byte(0x0F)
+		if digits[i*2+1] != 'F' {
+			hi = digits[i*2+1] - '0'
+		}
+		out[i] = lo | (hi << 4)
+	}
+	return out
+}
+
+// decodeSemiOctetNumber is the inverse of encodeSemiOctetNumber.
+func decodeSemiOctetNumber(data []byte, digitCount int) string {
+	var sb strings.Builder
+	for _, b := range data {
+		lo := b & 0x0F
+		hi := b >> 4
+		if sb.Len() < digitCount {
+			sb.WriteByte('0' + lo)
+		}
+		if sb.Len() < digitCount && hi != 0x0F {
+			sb.WriteByte('0' + hi)
+		}
+	}
+	return sb.String()
+}
+
+// encodeAddress encodes a destination/originator address field: a digit
+// count, a type-of-address byte (international if `number` starts with
+// "+", type of number unknown otherwise), and the semi-octet-packed digits.
+func encodeAddress(number string) []byte {
+	toa := byte(0x81) // type of number unknown, ISDN/telephone numbering plan
+	digits := number
+	if strings.HasPrefix(number, "+") {
+		toa = 0x91 // international, ISDN/telephone numbering plan
+		digits = number[1:]
+	}
+	out := []byte{byte(len(digits)), toa}
+	return append(out, encodeSemiOctetNumber(digits)...)
+}
+
+// concatHeader is the parsed 8-bit-reference concatenated SMS user data
+// header (information element 0x00).
+type concatHeader struct {
+	Reference byte
+	Total     int
+	Sequence  int
+}
+
+// udhBytes encodes a concatHeader as a User Data Header, including its own
+// length byte.
+func (h concatHeader) udhBytes() []byte {
+	return []byte{0x05, 0x00, 0x03, h.Reference, byte(h.Total), byte(h.Sequence)}
+}
+
+// EncodeSubmitPDU builds a single SMS-SUBMIT TPDU addressed to `to`
+// carrying `text` as its entire payload (the caller is responsible for
+// segmenting text that is too long for one PDU; see SplitSegments).
+// `udh`, if non-nil, is included as a concatenated-SMS User Data Header.
+//
+// It returns the PDU as the hex string AT+CMGS expects and the TP layer
+// length to pass as the AT+CMGS=<length> parameter.
+func EncodeSubmitPDU(to string, text string, reference byte, udh *concatHeader) (pduHex string, tpduLength int, err error) {
+	var pdu []byte
+
+	// SMSC info: 0x00 means "use the SMSC currently configured on the SIM".
+	pdu = append(pdu, 0x00)
+
+	firstOctet := byte(0x01) // TP-MTI = SMS-SUBMIT, TP-VPF = none
+	if udh != nil {
+		firstOctet |= 0x40 // TP-UDHI: user data starts with a header
+	}
+	pdu = append(pdu, firstOctet)
+	pdu = append(pdu, reference) // TP-MR; 0 lets the handset pick one too
+	pdu = append(pdu, encodeAddress(to)...)
+	pdu = append(pdu, 0x00) // TP-PID: normal
+
+	var userData []byte
+	var dcs byte
+	var udl int
+	fillBits := 0
+	if udh != nil {
+		fillBits = (7 - (len(udh.udhBytes())*8)%7) % 7
+	}
+
+	septets, ok := gsm7Septets(text)
+	if ok {
+		dcs = 0x00
+		packed := packSeptets(septets, fillBits)
+		if udh != nil {
+			userData = append(append([]byte{}, udh.udhBytes()...), packed...)
+			headerSeptets := (len(udh.udhBytes())*8 + 6) / 7
+			udl = headerSeptets + len(septets)
+		} else {
+			userData = packed
+			udl = len(septets)
+		}
+	} else {
+		dcs = 0x08
+		ucs2 := encodeUCS2(text)
+		if udh != nil {
+			userData = append(append([]byte{}, udh.udhBytes()...), ucs2...)
+			udl = len(udh.udhBytes()) + len(ucs2)
+		} else {
+			userData = ucs2
+			udl = len(ucs2)
+		}
+	}
+
+	if udl > 255 {
+		return "", 0, fmt.Errorf("text is too long for a single PDU (%d septets/octets of user data, max 255); split it with SplitSegments first", udl)
+	}
+
+	pdu = append(pdu, dcs)
+	pdu = append(pdu, byte(udl))
+	pdu = append(pdu, userData...)
+
+	// TP layer length excludes the SMSC info octet at the front.
+	tpduLength = len(pdu) - 1
+
+	return strings.ToUpper(hex.EncodeToString(pdu)), tpduLength, nil
+}
+
+// DecodedDeliver is the parsed result of an SMS-DELIVER PDU, as received
+// in a +CMT: URC.
+type DecodedDeliver struct {
+	From      string
+	Timestamp time.Time
+	Text      string
+	Concat    *concatHeader
+}
+
+// DecodeDeliverPDU parses an SMS-DELIVER TPDU (hex-encoded, as delivered
+// by a +CMT: URC) into its sender, timestamp, and text.
+func DecodeDeliverPDU(pduHex string) (DecodedDeliver, error) {
+	raw, err := hex.DecodeString(strings.TrimSpace(pduHex))
+	if err != nil {
+		return DecodedDeliver{}, fmt.Errorf("error decoding PDU hex: %w", err)
+	}
+	if len(raw) < 2 {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short")
+	}
+
+	i := 0
+	smscLen := int(raw[i])
+	i += 1 + smscLen
+
+	if i >= len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short after SMSC info")
+	}
+	firstOctet := raw[i]
+	hasUDH := firstOctet&0x40 != 0
+	i++
+
+	if i >= len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short before originator address")
+	}
+	addrDigits := int(raw[i])
+	addrBytes := (addrDigits + 1) / 2
+	i++ // TOA
+	i++
+	if i+addrBytes > len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short in originator address")
+	}
+	from := decodeSemiOctetNumber(raw[i:i+addrBytes], addrDigits)
+	i += addrBytes
+
+	if i >= len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short before TP-PID")
+	}
+	i++ // TP-PID
+
+	if i >= len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short before TP-DCS")
+	}
+	dcs := raw[i]
+	i++
+
+	if i+7 > len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short in TP-SCTS")
+	}
+	timestamp := decodeSCTS(raw[i : i+7])
+	i += 7
+
+	if i >= len(raw) {
+		return DecodedDeliver{}, fmt.Errorf("PDU too short before TP-UDL")
+	}
+	udl := int(raw[i])
+	i++
+
+	userData := raw[i:]
+
+	var concat *concatHeader
+	if hasUDH && len(userData) > 0 {
+		udhl := int(userData[0])
+		if 1+udhl <= len(userData) {
+			concat = parseConcatUDH(userData[1 : 1+udhl])
+		}
+	}
+
+	var text string
+	switch dcs {
+	case 0x08:
+		body := userData
+		if hasUDH {
+			udhl := int(userData[0])
+			body = userData[1+udhl:]
+		}
+		text = decodeUCS2(body)
+	default:
+		septets := unpackSeptets(userData, 0)
+		if hasUDH {
+			udhl := int(userData[0])
+			headerSeptets := ((1+udhl)*8 + 6) / 7 // ceil(headerBits/7)
+			if headerSeptets <= len(septets) {
+				septets = septets[headerSeptets:]
+			}
+			if udl >= headerSeptets {
+				udl -= headerSeptets
+			} else {
+				udl = 0
+			}
+		}
+		if udl > 0 && udl < len(septets) {
+			septets = septets[:udl]
+		}
+		text = decodeGSM7Septets(septets)
+	}
+
+	return DecodedDeliver{From: from, Timestamp: timestamp, Text: text, Concat: concat}, nil
+}
+
+// parseConcatUDH looks for the 8-bit-reference concatenation information
+// element (0x00) inside a User Data Header's information elements.
+func parseConcatUDH(ies []byte) *concatHeader {
+	i := 0
+	for i+1 < len(ies) {
+		iei := ies[i]
+		iedl := int(ies[i+1])
+		if i+2+iedl > len(ies) {
+			return nil
+		}
+		if iei == 0x00 && iedl == 3 {
+			data := ies[i+2 : i+2+iedl]
+			return &concatHeader{Reference: data[0], Total: int(data[1]), Sequence: int(data[2])}
+		}
+		i += 2 + iedl
+	}
+	return nil
+}
+
+// decodeSCTS decodes a TP-SCTS timestamp: 7 semi-octet-swapped
+// (YY,MM,DD,hh,mm,ss,tz) fields.
+func decodeSCTS(data []byte) time.Time {
+	swap := func(b byte) int {
+		return int(b&0x0F)*10 + int(b>>4)
+	}
+	year := 2000 + swap(data[0])
+	month := swap(data[1])
+	day := swap(data[2])
+	hour := swap(data[3])
+	min := swap(data[4])
+	sec := swap(data[5])
+
+	tzQuarters := int(data[6]&0x0F)*10 + int((data[6]>>4)&0x7)
+	if data[6]&0x80 != 0 {
+		tzQuarters = -tzQuarters
+	}
+	loc := time.FixedZone("", tzQuarters*15*60)
+	return time.Date(year, time.Month(month), day, hour, min, sec, 0, loc)
+}
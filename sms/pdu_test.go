@@ -0,0 +1,123 @@
+package sms
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeSubmitPDURoundTrip(t *testing.T) {
+	pduHex, tpduLen, err := EncodeSubmitPDU("+15551234567", "Hello, world!", 0, nil)
+	if err != nil {
+		t.Fatalf("EncodeSubmitPDU() returned error: %v", err)
+	}
+	if pduHex == "" {
+		t.Fatalf("EncodeSubmitPDU() returned empty PDU hex")
+	}
+	if tpduLen <= 0 {
+		t.Fatalf("EncodeSubmitPDU() returned non-positive tpduLength %d", tpduLen)
+	}
+	if pduHex != strings.ToUpper(pduHex) {
+		t.Errorf("EncodeSubmitPDU() hex %q is not upper-cased", pduHex)
+	}
+}
+
+func TestEncodeSubmitPDUOversizedUserData(t *testing.T) {
+	// 256 extension-table characters cost 512 septets, which cannot fit in
+	// the one-byte TP-UDL field.
+	_, _, err := EncodeSubmitPDU("+15551234567", strings.Repeat("{", 256), 0, nil)
+	if err == nil {
+		t.Fatalf("EncodeSubmitPDU() expected an error for oversized user data, got none")
+	}
+}
+
+// buildDeliverPDU hand-assembles an SMS-DELIVER TPDU (no SMSC info, no
+// UDH) from the same byte-level helpers EncodeSubmitPDU uses, since this
+// package has no exported SMS-DELIVER encoder of its own.
+func buildDeliverPDU(t *testing.T, from, text string) []byte {
+	t.Helper()
+	septets, ok := gsm7Septets(text)
+	if !ok {
+		t.Fatalf("text %q is not representable in GSM 7-bit", text)
+	}
+
+	var raw []byte
+	raw = append(raw, 0x00)       // no SMSC info
+	raw = append(raw, 0x00)       // TP-MTI = SMS-DELIVER, no UDH
+	raw = append(raw, encodeAddress(from)...)
+	raw = append(raw, 0x00) // TP-PID
+	raw = append(raw, 0x00) // TP-DCS: GSM 7-bit
+	raw = append(raw, 0x32, 0x70, 0x52, 0x70, 0x22, 0x21, 0x00) // TP-SCTS: 2023-07-25 07:22:12+00
+	raw = append(raw, byte(len(septets)))
+	raw = append(raw, packSeptets(septets, 0)...)
+	return raw
+}
+
+func TestDecodeDeliverPDU(t *testing.T) {
+	raw := buildDeliverPDU(t, "+15551234567", "Hi")
+
+	decoded, err := DecodeDeliverPDU(strings.ToUpper(hex.EncodeToString(raw)))
+	if err != nil {
+		t.Fatalf("DecodeDeliverPDU() returned error: %v", err)
+	}
+	if decoded.From != "15551234567" {
+		t.Errorf("From = %q, want %q", decoded.From, "15551234567")
+	}
+	if decoded.Text != "Hi" {
+		t.Errorf("Text = %q, want %q", decoded.Text, "Hi")
+	}
+	wantTime := time.Date(2023, time.July, 25, 7, 22, 12, 0, time.FixedZone("", 0))
+	if !decoded.Timestamp.Equal(wantTime) {
+		t.Errorf("Timestamp = %v, want %v", decoded.Timestamp, wantTime)
+	}
+	if decoded.Concat != nil {
+		t.Errorf("Concat = %+v, want nil", decoded.Concat)
+	}
+}
+
+func TestDecodeDeliverPDUTooShort(t *testing.T) {
+	if _, err := DecodeDeliverPDU("00"); err == nil {
+		t.Errorf("DecodeDeliverPDU() expected an error for a too-short PDU, got none")
+	}
+}
+
+func TestGSM7SeptetsRoundTrip(t *testing.T) {
+	tests := []string{
+		"Hello, world!",
+		"{}[]~\\|^€",
+		"",
+	}
+	for _, text := range tests {
+		septets, ok := gsm7Septets(text)
+		if !ok {
+			t.Fatalf("gsm7Septets(%q) = _, false, want true", text)
+		}
+		packed := packSeptets(septets, 0)
+		unpacked := unpackSeptets(packed, 0)
+		if len(unpacked) > len(septets) {
+			unpacked = unpacked[:len(septets)]
+		}
+		got := decodeGSM7Septets(unpacked)
+		if got != text {
+			t.Errorf("round-trip of %q = %q", text, got)
+		}
+	}
+}
+
+func TestGSM7SeptetsRejectsNonGSM7(t *testing.T) {
+	if _, ok := gsm7Septets("日本語"); ok {
+		t.Errorf("gsm7Septets() = _, true for non-GSM7 text, want false")
+	}
+}
+
+func TestSemiOctetNumberRoundTrip(t *testing.T) {
+	tests := []string{"15551234567", "1234"}
+	for _, digits := range tests {
+		encoded := encodeSemiOctetNumber(digits)
+		got := decodeSemiOctetNumber(encoded, len(digits))
+		if got != digits {
+			t.Errorf("semi-octet round-trip of %q = %q", digits, got)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package sms implements PDU-mode SMS send and receive on top of a
+// wavesharecomm.Modem.
+package sms
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/Potsdam-Sensors/waveshare-lte-hat-pi/wavesharecomm"
+)
+
+const (
+	gsm7SingleLimit    = 160
+	gsm7ConcatLimit    = 153
+	ucs2SingleLimit    = 70
+	ucs2ConcatLimit    = 67
+	defaultSendTimeout = 10 * time.Second
+
+	// defaultPartialTimeout is how long an incomplete concatenated SMS is
+	// kept around waiting for its missing segments before being dropped.
+	defaultPartialTimeout = 5 * time.Minute
+)
+
+// Message is a received SMS, reassembled from all of its concatenated
+// parts if it was sent as more than one.
+type Message struct {
+	From         string
+	Timestamp    time.Time
+	Text         string
+	Concatenated bool
+}
+
+// MessageRef identifies one segment of a sent SMS. A message short enough
+// for a single PDU produces one MessageRef with TotalParts 1.
+type MessageRef struct {
+	Reference  byte
+	Part       int
+	TotalParts int
+}
+
+// partialKey identifies an in-progress concatenated SMS by sender and
+// concatenation reference. The GSM 03.40 8-bit reference is only unique
+// per-sender, not globally.
+type partialKey struct {
+	from string
+	ref  byte
+}
+
+type partialMessage struct {
+	total     int
+	parts     map[int]string
+	timestamp time.Time
+	timer     *time.Timer
+}
+
+// Client sends and receives SMS in PDU mode over a Modem. Construct one
+// with NewClient per Modem; it switches the modem into PDU mode and
+// subscribes to incoming message URCs.
+type Client struct {
+	modem          *wavesharecomm.Modem
+	urcCh          <-chan wavesharecomm.URC
+	inbox          chan Message
+	partialTimeout time.Duration
+
+	mu      sync.Mutex
+	partial map[partialKey]*partialMessage
+}
+
+// NewClient puts `modem` into PDU mode, registers for "+CMT:" delivery
+// URCs (AT+CNMI=2,2,0,0,0 — deliver new SMS directly rather than just
+// indicating storage), and starts the background reassembly loop. Incomplete
+// concatenated messages are dropped after defaultPartialTimeout; use
+// NewClientWithTimeout to configure that.
+func NewClient(modem *wavesharecomm.Modem) (*Client, error) {
+	return NewClientWithTimeout(modem, defaultPartialTimeout)
+}
+
+// NewClientWithTimeout is NewClient, but with the timeout an incomplete
+// concatenated SMS is kept around waiting for its missing segments
+// configurable instead of fixed at defaultPartialTimeout.
+func NewClientWithTimeout(modem *wavesharecomm.Modem, partialTimeout time.Duration) (*Client, error) {
+	if _, ok, err := modem.ExecuteCommand("+CMGF=0", 2*time.Second); err != nil {
+		return nil, fmt.Errorf("error enabling PDU mode: %w", err)
+	} else if !ok {
+		return nil, fmt.Errorf("modem rejected AT+CMGF=0")
+	}
+	if _, ok, err := modem.ExecuteCommand("+CNMI=2,2,0,0,0", 2*time.Second); err != nil {
+		return nil, fmt.Errorf("error registering SMS URCs: %w", err)
+	} else if !ok {
+		return nil, fmt.Errorf("modem rejected AT+CNMI=2,2,0,0,0")
+	}
+
+	c := &Client{
+		modem:          modem,
+		urcCh:          modem.Subscribe("+CMT:"),
+		inbox:          make(chan Message, 16),
+		partialTimeout: partialTimeout,
+		partial:        make(map[partialKey]*partialMessage),
+	}
+	go c.run()
+	return c, nil
+}
+
+// Inbox returns the channel that reassembled, decoded messages are
+// delivered on.
+func (c *Client) Inbox() <-chan Message {
+	return c.inbox
+}
+
+// Send segments `body` as needed and transmits one SMS-SUBMIT PDU per
+// segment, returning a MessageRef per segment sent. It stops and returns
+// an error (along with the refs already sent) if `ctx` is canceled or a
+// segment fails to send.
+func (c *Client) Send(ctx context.Context, to string, body string) ([]MessageRef, error) {
+	segments := SplitSegments(body)
+	reference := newReference()
+
+	var refs []MessageRef
+	for i, segment := range segments {
+		select {
+		case <-ctx.Done():
+			return refs, ctx.Err()
+		default:
+		}
+
+		var udh *concatHeader
+		if len(segments) > 1 {
+			udh = &concatHeader{Reference: reference, Total: len(segments), Sequence: i + 1}
+		}
+
+		pduHex, tpduLen, err := EncodeSubmitPDU(to, segment, 0, udh)
+		if err != nil {
+			return refs, fmt.Errorf("error encoding PDU for segment %d: %w", i+1, err)
+		}
+
+		_, ok, err := c.modem.ExecutePromptCommand(fmt.Sprintf("+CMGS=%d", tpduLen), []byte(pduHex), defaultSendTimeout)
+		if err != nil {
+			return refs, fmt.Errorf("error sending segment %d: %w", i+1, err)
+		}
+		if !ok {
+			return refs, fmt.Errorf("modem rejected segment %d", i+1)
+		}
+
+		refs = append(refs, MessageRef{Reference: reference, Part: i + 1, TotalParts: len(segments)})
+	}
+	return refs, nil
+}
+
+// SplitSegments breaks `text` into the PDUs needed to send it, choosing
+// GSM 7-bit or UCS-2 packing and, for text that does not fit in one PDU,
+// segmenting at the 153/67 septet/character concatenated-SMS limits.
+//
+// Segmentation is by encoded size, not rune count: a GSM 7-bit extension
+// character (e.g. "{", "}", "€") costs two septets via the 0x1B escape, so
+// counting runes alone can under-segment a message and overflow a PDU's
+// user data length.
+func SplitSegments(text string) []string {
+	runes := []rune(text)
+
+	costs := make([]int, len(runes))
+	isGSM7 := true
+	for i, r := range runes {
+		cost, ok := gsm7RuneSeptetCost(r)
+		if !ok {
+			isGSM7 = false
+			break
+		}
+		costs[i] = cost
+	}
+
+	if !isGSM7 {
+		// UCS-2 text is encoded as UTF-16 (see encodeUCS2): one 2-octet
+		// unit per rune, or two units (a surrogate pair) for a rune outside
+		// the Basic Multilingual Plane, so the 70/67 limits count units.
+		if len(utf16.Encode(runes)) <= ucs2SingleLimit {
+			return []string{text}
+		}
+		var segments []string
+		start, units := 0, 0
+		for i, r := range runes {
+			cost := len(utf16.Encode([]rune{r}))
+			if units+cost > ucs2ConcatLimit && i > start {
+				segments = append(segments, string(runes[start:i]))
+				start, units = i, 0
+			}
+			units += cost
+		}
+		return append(segments, string(runes[start:]))
+	}
+
+	total := 0
+	for _, c := range costs {
+		total += c
+	}
+	if total <= gsm7SingleLimit {
+		return []string{text}
+	}
+
+	var segments []string
+	start, septets := 0, 0
+	for i := range runes {
+		if septets+costs[i] > gsm7ConcatLimit && i > start {
+			segments = append(segments, string(runes[start:i]))
+			start, septets = i, 0
+		}
+		septets += costs[i]
+	}
+	segments = append(segments, string(runes[start:]))
+	return segments
+}
+
+var (
+	referenceMu sync.Mutex
+	nextRef     byte
+)
+
+// newReference hands out the next 8-bit concatenated-SMS reference
+// number, wrapping at 256.
+func newReference() byte {
+	referenceMu.Lock()
+	defer referenceMu.Unlock()
+	ref := nextRef
+	nextRef++
+	return ref
+}
+
+// run reassembles incoming +CMT: URCs into complete Messages.
+func (c *Client) run() {
+	for urc := range c.urcCh {
+		if len(urc.Lines) < 2 {
+			continue
+		}
+		decoded, err := DecodeDeliverPDU(string(urc.Lines[1]))
+		if err != nil {
+			log.Printf("sms: error decoding delivered PDU: %v", err)
+			continue
+		}
+
+		if decoded.Concat == nil {
+			c.inbox <- Message{From: decoded.From, Timestamp: decoded.Timestamp, Text: decoded.Text}
+			continue
+		}
+
+		c.addPart(decoded)
+	}
+}
+
+// addPart records one segment of a concatenated SMS and delivers it to
+// the inbox once every segment has arrived.
+func (c *Client) addPart(decoded DecodedDeliver) {
+	key := partialKey{from: decoded.From, ref: decoded.Concat.Reference}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pm, found := c.partial[key]
+	if !found {
+		pm = &partialMessage{total: decoded.Concat.Total, parts: make(map[int]string), timestamp: decoded.Timestamp}
+		pm.timer = time.AfterFunc(c.partialTimeout, func() {
+			c.mu.Lock()
+			delete(c.partial, key)
+			c.mu.Unlock()
+		})
+		c.partial[key] = pm
+	}
+	pm.parts[decoded.Concat.Sequence] = decoded.Text
+
+	if len(pm.parts) < pm.total {
+		return
+	}
+
+	pm.timer.Stop()
+	delete(c.partial, key)
+
+	var text string
+	for i := 1; i <= pm.total; i++ {
+		text += pm.parts[i]
+	}
+	c.inbox <- Message{From: decoded.From, Timestamp: pm.timestamp, Text: text, Concatenated: true}
+}
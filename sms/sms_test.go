@@ -0,0 +1,86 @@
+package sms
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitSegmentsSingleSegment(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{name: "short GSM7", text: "Hello, world!"},
+		{name: "short UCS2", text: "こんにちは"},
+		{name: "160 basic GSM7 chars", text: strings.Repeat("a", gsm7SingleLimit)},
+		{name: "70 UCS2 chars", text: strings.Repeat("日", ucs2SingleLimit)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			segments := SplitSegments(tt.text)
+			if len(segments) != 1 {
+				t.Fatalf("SplitSegments(%q) returned %d segments, want 1", tt.name, len(segments))
+			}
+			if segments[0] != tt.text {
+				t.Errorf("SplitSegments(%q)[0] = %q, want original text", tt.name, segments[0])
+			}
+		})
+	}
+}
+
+func TestSplitSegmentsExtensionCharsForceMultipleSegments(t *testing.T) {
+	// Each "{" costs 2 septets, so 160 of them is 320 septets: well past the
+	// single-PDU limit even though it's only 160 runes.
+	text := strings.Repeat("{", 160)
+	segments := SplitSegments(text)
+	if len(segments) < 2 {
+		t.Fatalf("SplitSegments() returned %d segments for 320 septets of extension chars, want > 1", len(segments))
+	}
+	reassembled := strings.Join(segments, "")
+	if reassembled != text {
+		t.Errorf("segments do not reassemble to the original text")
+	}
+	for i, seg := range segments {
+		septets, ok := gsm7Septets(seg)
+		if !ok {
+			t.Fatalf("segment %d is not valid GSM7", i)
+		}
+		if len(septets) > gsm7ConcatLimit {
+			t.Errorf("segment %d has %d septets, want <= %d", i, len(septets), gsm7ConcatLimit)
+		}
+	}
+}
+
+func TestSplitSegmentsLongPlainGSM7(t *testing.T) {
+	text := strings.Repeat("a", gsm7SingleLimit+1)
+	segments := SplitSegments(text)
+	if len(segments) < 2 {
+		t.Fatalf("SplitSegments() returned %d segments for text past the single-segment limit, want > 1", len(segments))
+	}
+	for i, seg := range segments {
+		runeCount := len([]rune(seg))
+		if runeCount > gsm7ConcatLimit {
+			t.Errorf("segment %d has %d runes, want <= %d", i, runeCount, gsm7ConcatLimit)
+		}
+	}
+	if strings.Join(segments, "") != text {
+		t.Errorf("segments do not reassemble to the original text")
+	}
+}
+
+func TestSplitSegmentsLongUCS2(t *testing.T) {
+	text := strings.Repeat("日", ucs2SingleLimit+1)
+	segments := SplitSegments(text)
+	if len(segments) < 2 {
+		t.Fatalf("SplitSegments() returned %d segments for UCS2 text past the single-segment limit, want > 1", len(segments))
+	}
+	for i, seg := range segments {
+		runeCount := len([]rune(seg))
+		if runeCount > ucs2ConcatLimit {
+			t.Errorf("segment %d has %d runes, want <= %d", i, runeCount, ucs2ConcatLimit)
+		}
+	}
+	if strings.Join(segments, "") != text {
+		t.Errorf("segments do not reassemble to the original text")
+	}
+}
@@ -0,0 +1,371 @@
+package wavesharecomm
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command is a typed AT command: Format returns the text to append after
+// "AT" (e.g. "+COPS?"), and Parse turns the response lines collected before
+// the terminal OK into a typed result. Use it with Modem.RunCommand instead
+// of hand-parsing ExecuteCommand's raw []byte response.
+type Command interface {
+	Format() string
+	Parse(resp [][]byte) (any, error)
+}
+
+// findPrefixLine returns the first line in resp that starts with prefix,
+// with the prefix (and any following space) stripped.
+func findPrefixLine(resp [][]byte, prefix string) ([]byte, bool) {
+	for _, line := range resp {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return bytes.TrimSpace(line[len(prefix):]), true
+		}
+	}
+	return nil, false
+}
+
+func splitFields(line []byte) []string {
+	fields := strings.Split(string(line), ",")
+	for i, f := range fields {
+		fields[i] = strings.Trim(strings.TrimSpace(f), `"`)
+	}
+	return fields
+}
+
+// COPSQuery is AT+COPS?, reporting the currently selected network operator.
+type COPSQuery struct{}
+
+func (COPSQuery) Format() string { return "+COPS?" }
+
+// OperatorInfo is the parsed result of COPSQuery.
+type OperatorInfo struct {
+	Mode       int
+	Format     int
+	Operator   string
+	MCC        string
+	MNC        string
+	AccessTech int
+}
+
+func (COPSQuery) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+COPS:")
+	if !found {
+		return nil, fmt.Errorf("no +COPS: line in response")
+	}
+	fields := splitFields(line)
+	info := OperatorInfo{}
+	if len(fields) > 0 {
+		info.Mode, _ = strconv.Atoi(fields[0])
+	}
+	if len(fields) > 1 {
+		info.Format, _ = strconv.Atoi(fields[1])
+	}
+	if len(fields) > 2 {
+		info.Operator = fields[2]
+		if info.Format == 2 && len(info.Operator) >= 5 {
+			info.MCC = info.Operator[:3]
+			info.MNC = info.Operator[3:]
+		}
+	}
+	if len(fields) > 3 {
+		info.AccessTech, _ = strconv.Atoi(fields[3])
+	}
+	return info, nil
+}
+
+// CSQQuery is AT+CSQ, reporting received signal strength and bit error
+// rate.
+type CSQQuery struct{}
+
+func (CSQQuery) Format() string { return "+CSQ" }
+
+// SignalQuality is the parsed result of CSQQuery. RSSI is the raw 3GPP
+// index (0-31, 99 = unknown); BER is the raw channel bit error rate index
+// (0-7, 99 = unknown).
+type SignalQuality struct {
+	RSSI int
+	BER  int
+}
+
+func (CSQQuery) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+CSQ:")
+	if !found {
+		return nil, fmt.Errorf("no +CSQ: line in response")
+	}
+	fields := splitFields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("malformed +CSQ: line %q", line)
+	}
+	sq := SignalQuality{}
+	sq.RSSI, _ = strconv.Atoi(fields[0])
+	sq.BER, _ = strconv.Atoi(fields[1])
+	return sq, nil
+}
+
+// RegistrationStatus is the parsed result of CREGQuery and CEREGQuery.
+type RegistrationStatus struct {
+	URCMode    int
+	Stat       int
+	LAC        string
+	CI         string
+	AccessTech int
+}
+
+func parseRegistrationStatus(resp [][]byte, prefix string) (RegistrationStatus, error) {
+	line, found := findPrefixLine(resp, prefix)
+	if !found {
+		return RegistrationStatus{}, fmt.Errorf("no %s line in response", prefix)
+	}
+	fields := splitFields(line)
+	if len(fields) < 2 {
+		return RegistrationStatus{}, fmt.Errorf("malformed %s line %q", prefix, line)
+	}
+	rs := RegistrationStatus{}
+	rs.URCMode, _ = strconv.Atoi(fields[0])
+	rs.Stat, _ = strconv.Atoi(fields[1])
+	if len(fields) > 2 {
+		rs.LAC = fields[2]
+	}
+	if len(fields) > 3 {
+		rs.CI = fields[3]
+	}
+	if len(fields) > 4 {
+		rs.AccessTech, _ = strconv.Atoi(fields[4])
+	}
+	return rs, nil
+}
+
+// CREGQuery is AT+CREG?, reporting circuit-switched network registration.
+type CREGQuery struct{}
+
+func (CREGQuery) Format() string { return "+CREG?" }
+func (CREGQuery) Parse(resp [][]byte) (any, error) {
+	return parseRegistrationStatus(resp, "+CREG:")
+}
+
+// CEREGQuery is AT+CEREG?, reporting EPS (LTE) network registration.
+type CEREGQuery struct{}
+
+func (CEREGQuery) Format() string { return "+CEREG?" }
+func (CEREGQuery) Parse(resp [][]byte) (any, error) {
+	return parseRegistrationStatus(resp, "+CEREG:")
+}
+
+// CGDCONTQuery is AT+CGDCONT?, listing the defined PDP contexts.
+type CGDCONTQuery struct{}
+
+func (CGDCONTQuery) Format() string { return "+CGDCONT?" }
+
+// PDPContext is one defined PDP context, as reported by CGDCONTQuery.
+type PDPContext struct {
+	CID     int
+	Type    string
+	APN     string
+	Address string
+}
+
+func (CGDCONTQuery) Parse(resp [][]byte) (any, error) {
+	var contexts []PDPContext
+	for _, line := range resp {
+		if !bytes.HasPrefix(line, []byte("+CGDCONT:")) {
+			continue
+		}
+		fields := splitFields(bytes.TrimSpace(line[len("+CGDCONT:"):]))
+		ctx := PDPContext{}
+		if len(fields) > 0 {
+			ctx.CID, _ = strconv.Atoi(fields[0])
+		}
+		if len(fields) > 1 {
+			ctx.Type = fields[1]
+		}
+		if len(fields) > 2 {
+			ctx.APN = fields[2]
+		}
+		if len(fields) > 3 {
+			ctx.Address = fields[3]
+		}
+		contexts = append(contexts, ctx)
+	}
+	return contexts, nil
+}
+
+// CGPSINFOQuery is AT+CGPSINFO, reporting the current GPS fix.
+type CGPSINFOQuery struct{}
+
+func (CGPSINFOQuery) Format() string { return "+CGPSINFO" }
+
+// GPSFix is the parsed result of CGPSINFOQuery. Fix is false (and the
+// remaining fields are zero) when the modem has not yet acquired a fix.
+type GPSFix struct {
+	Fix            bool
+	Latitude       float64
+	Longitude      float64
+	Timestamp      time.Time
+	AltitudeMeters float64
+	SpeedKnots     float64
+	CourseDegrees  float64
+}
+
+// parseNmeaCoordinate converts a "ddmm.mmmmmm"/"dddmm.mmmmmm" coordinate
+// with the given number of integer degree digits into decimal degrees.
+func parseNmeaCoordinate(raw string, degreeDigits int) (float64, error) {
+	if len(raw) <= degreeDigits {
+		return 0, fmt.Errorf("coordinate %q too short", raw)
+	}
+	degrees, err := strconv.ParseFloat(raw[:degreeDigits], 64)
+	if err != nil {
+		return 0, err
+	}
+	minutes, err := strconv.ParseFloat(raw[degreeDigits:], 64)
+	if err != nil {
+		return 0, err
+	}
+	return degrees + minutes/60, nil
+}
+
+func (CGPSINFOQuery) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+CGPSINFO:")
+	if !found {
+		return nil, fmt.Errorf("no +CGPSINFO: line in response")
+	}
+	fields := splitFields(line)
+	if len(fields) < 9 {
+		return nil, fmt.Errorf("malformed +CGPSINFO: line %q", line)
+	}
+	if fields[0] == "" {
+		return GPSFix{}, nil
+	}
+
+	lat, err := parseNmeaCoordinate(fields[0], 2)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing latitude: %w", err)
+	}
+	if fields[1] == "S" {
+		lat = -lat
+	}
+	lon, err := parseNmeaCoordinate(fields[2], 3)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing longitude: %w", err)
+	}
+	if fields[3] == "W" {
+		lon = -lon
+	}
+
+	timestamp, err := time.Parse("020106-150405.0", fields[4]+"-"+fields[5])
+	if err != nil {
+		return nil, fmt.Errorf("error parsing GPS timestamp: %w", err)
+	}
+
+	fix := GPSFix{Fix: true, Latitude: lat, Longitude: lon, Timestamp: timestamp}
+	fix.AltitudeMeters, _ = strconv.ParseFloat(fields[6], 64)
+	fix.SpeedKnots, _ = strconv.ParseFloat(fields[7], 64)
+	fix.CourseDegrees, _ = strconv.ParseFloat(fields[8], 64)
+	return fix, nil
+}
+
+// CCLKQuery is AT+CCLK?, reporting the modem's real-time clock.
+type CCLKQuery struct{}
+
+func (CCLKQuery) Format() string { return "+CCLK?" }
+
+// ClockInfo is the parsed result of CCLKQuery.
+type ClockInfo struct {
+	Time time.Time
+}
+
+func (CCLKQuery) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+CCLK:")
+	if !found {
+		return nil, fmt.Errorf("no +CCLK: line in response")
+	}
+	raw := strings.Trim(string(line), `"`)
+	t, err := time.Parse("06/01/02,15:04:05Z07:00", raw)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing +CCLK timestamp %q: %w", raw, err)
+	}
+	return ClockInfo{Time: t}, nil
+}
+
+// CPINQuery is AT+CPIN?, reporting whether the SIM is ready or awaiting a
+// PIN/PUK.
+type CPINQuery struct{}
+
+func (CPINQuery) Format() string { return "+CPIN?" }
+
+// SIMStatus is the parsed result of CPINQuery, e.g. "READY", "SIM PIN",
+// "SIM PUK".
+type SIMStatus struct {
+	Status string
+}
+
+func (CPINQuery) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+CPIN:")
+	if !found {
+		return nil, fmt.Errorf("no +CPIN: line in response")
+	}
+	return SIMStatus{Status: string(line)}, nil
+}
+
+// CIMIQuery is AT+CIMI, reporting the SIM's IMSI.
+type CIMIQuery struct{}
+
+func (CIMIQuery) Format() string { return "+CIMI" }
+func (CIMIQuery) Parse(resp [][]byte) (any, error) {
+	for _, line := range resp {
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			return string(trimmed), nil
+		}
+	}
+	return nil, fmt.Errorf("no IMSI line in response")
+}
+
+// CGSNQuery is AT+CGSN, reporting the modem's IMEI.
+type CGSNQuery struct{}
+
+func (CGSNQuery) Format() string { return "+CGSN" }
+func (CGSNQuery) Parse(resp [][]byte) (any, error) {
+	for _, line := range resp {
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			return string(trimmed), nil
+		}
+	}
+	return nil, fmt.Errorf("no IMEI line in response")
+}
+
+// CIPRXGetQueryUnread is AT+CIPRXGET=4,<linkID>, reporting how many bytes
+// are buffered and unread on a TCP/UDP link opened with +CIPOPEN.
+type CIPRXGetQueryUnread struct {
+	LinkID int
+}
+
+func (c CIPRXGetQueryUnread) Format() string { return fmt.Sprintf("+CIPRXGET=4,%d", c.LinkID) }
+
+// RxBufferStatus is the parsed result of CIPRXGetQueryUnread.
+type RxBufferStatus struct {
+	LinkID        int
+	UnreadBytes   int
+	TotalReceived int
+}
+
+func (CIPRXGetQueryUnread) Parse(resp [][]byte) (any, error) {
+	line, found := findPrefixLine(resp, "+CIPRXGET:")
+	if !found {
+		return nil, fmt.Errorf("no +CIPRXGET: line in response")
+	}
+	fields := splitFields(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("malformed +CIPRXGET: line %q", line)
+	}
+	status := RxBufferStatus{}
+	linkID, _ := strconv.Atoi(fields[1])
+	status.LinkID = linkID
+	status.UnreadBytes, _ = strconv.Atoi(fields[2])
+	if len(fields) > 3 {
+		status.TotalReceived, _ = strconv.Atoi(fields[3])
+	}
+	return status, nil
+}
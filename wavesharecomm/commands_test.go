@@ -0,0 +1,133 @@
+package wavesharecomm
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommandParsers(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Command
+		resp [][]byte
+		want any
+	}{
+		{
+			name: "COPSQuery",
+			cmd:  COPSQuery{},
+			resp: [][]byte{[]byte(`+COPS: 0,2,"31026",7`)},
+			want: OperatorInfo{Mode: 0, Format: 2, Operator: "31026", MCC: "310", MNC: "26", AccessTech: 7},
+		},
+		{
+			name: "CSQQuery",
+			cmd:  CSQQuery{},
+			resp: [][]byte{[]byte("+CSQ: 20,99")},
+			want: SignalQuality{RSSI: 20, BER: 99},
+		},
+		{
+			name: "CREGQuery",
+			cmd:  CREGQuery{},
+			resp: [][]byte{[]byte(`+CREG: 2,1,"1A2B","3C4D",7`)},
+			want: RegistrationStatus{URCMode: 2, Stat: 1, LAC: "1A2B", CI: "3C4D", AccessTech: 7},
+		},
+		{
+			name: "CEREGQuery",
+			cmd:  CEREGQuery{},
+			resp: [][]byte{[]byte("+CEREG: 0,5")},
+			want: RegistrationStatus{URCMode: 0, Stat: 5},
+		},
+		{
+			name: "CGDCONTQuery",
+			cmd:  CGDCONTQuery{},
+			resp: [][]byte{
+				[]byte(`+CGDCONT: 1,"IP","internet","10.0.0.1"`),
+				[]byte(`+CGDCONT: 2,"IPV6","ims",""`),
+			},
+			want: []PDPContext{
+				{CID: 1, Type: "IP", APN: "internet", Address: "10.0.0.1"},
+				{CID: 2, Type: "IPV6", APN: "ims", Address: ""},
+			},
+		},
+		{
+			name: "CGPSINFOQuery no fix",
+			cmd:  CGPSINFOQuery{},
+			resp: [][]byte{[]byte("+CGPSINFO: ,,,,,,,,")},
+			want: GPSFix{},
+		},
+		{
+			name: "CGPSINFOQuery with fix",
+			cmd:  CGPSINFOQuery{},
+			resp: [][]byte{[]byte("+CGPSINFO: 3113.343286,N,12121.024770,E,250723,072212.0,44.1,0.0,0")},
+			want: GPSFix{
+				Fix:            true,
+				Latitude:       31 + 13.343286/60,
+				Longitude:      121 + 21.024770/60,
+				Timestamp:      time.Date(2023, time.July, 25, 7, 22, 12, 0, time.UTC),
+				AltitudeMeters: 44.1,
+			},
+		},
+		{
+			name: "CCLKQuery",
+			cmd:  CCLKQuery{},
+			resp: [][]byte{[]byte(`+CCLK: "23/07/25,07:22:12+08:00"`)},
+			want: ClockInfo{Time: time.Date(2023, time.July, 25, 7, 22, 12, 0, time.FixedZone("", 8*60*60))},
+		},
+		{
+			name: "CPINQuery",
+			cmd:  CPINQuery{},
+			resp: [][]byte{[]byte("+CPIN: READY")},
+			want: SIMStatus{Status: "READY"},
+		},
+		{
+			name: "CIMIQuery",
+			cmd:  CIMIQuery{},
+			resp: [][]byte{[]byte("460001234567890")},
+			want: "460001234567890",
+		},
+		{
+			name: "CGSNQuery",
+			cmd:  CGSNQuery{},
+			resp: [][]byte{[]byte("861234567890123")},
+			want: "861234567890123",
+		},
+		{
+			name: "CIPRXGetQueryUnread",
+			cmd:  CIPRXGetQueryUnread{LinkID: 0},
+			resp: [][]byte{[]byte("+CIPRXGET: 4,0,128,512")},
+			want: RxBufferStatus{LinkID: 0, UnreadBytes: 128, TotalReceived: 512},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Parse(tt.resp)
+			if err != nil {
+				t.Fatalf("Parse() returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandParsersError(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  Command
+		resp [][]byte
+	}{
+		{name: "COPSQuery missing line", cmd: COPSQuery{}, resp: [][]byte{[]byte("OK")}},
+		{name: "CSQQuery malformed", cmd: CSQQuery{}, resp: [][]byte{[]byte("+CSQ: 20")}},
+		{name: "CIMIQuery empty", cmd: CIMIQuery{}, resp: [][]byte{[]byte("   ")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cmd.Parse(tt.resp); err == nil {
+				t.Errorf("Parse() expected an error, got none")
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package wavesharecomm
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// ATError is returned when the modem answers a command with a
+// "+CME ERROR: <n>" or "+CMS ERROR: <n>" terminal line instead of plain
+// ERROR. It carries the numeric code so callers can branch on it, and a
+// human-readable string looked up from the standard 3GPP error tables.
+type ATError struct {
+	Kind string // "CME" or "CMS"
+	Code int
+}
+
+func (e *ATError) Error() string {
+	return fmt.Sprintf("+%s ERROR: %d (%s)", e.Kind, e.Code, e.Description())
+}
+
+// Description returns the standard 3GPP string for the error code, or
+// "unknown error" if this library does not recognize it.
+func (e *ATError) Description() string {
+	var table map[int]string
+	if e.Kind == "CMS" {
+		table = cmsErrorStrings
+	} else {
+		table = cmeErrorStrings
+	}
+	if s, found := table[e.Code]; found {
+		return s
+	}
+	return "unknown error"
+}
+
+// parseATError inspects a trimmed terminal line and, if it is a
+// "+CME ERROR:" or "+CMS ERROR:" line, returns the corresponding ATError.
+// Returns nil for a plain "OK" or "ERROR" line.
+func parseATError(trimmedLine []byte) error {
+	for _, kind := range [...]string{"CME", "CMS"} {
+		prefix := []byte("+" + kind + " ERROR:")
+		if !bytes.HasPrefix(trimmedLine, prefix) {
+			continue
+		}
+		codeStr := string(bytes.TrimSpace(trimmedLine[len(prefix):]))
+		code, err := strconv.Atoi(codeStr)
+		if err != nil {
+			return fmt.Errorf("could not parse %s error code from %q: %w", kind, trimmedLine, err)
+		}
+		return &ATError{Kind: kind, Code: code}
+	}
+	return nil
+}
+
+// cmeErrorStrings covers the 3GPP TS 27.007 final result code error
+// values most commonly seen on the SIM7600.
+var cmeErrorStrings = map[int]string{
+	0:   "phone failure",
+	1:   "no connection to phone",
+	2:   "phone-adaptor link reserved",
+	3:   "operation not allowed",
+	4:   "operation not supported",
+	5:   "PH-SIM PIN required",
+	10:  "SIM not inserted",
+	11:  "SIM PIN required",
+	12:  "SIM PUK required",
+	13:  "SIM failure",
+	14:  "SIM busy",
+	15:  "SIM wrong",
+	16:  "incorrect password",
+	17:  "SIM PIN2 required",
+	18:  "SIM PUK2 required",
+	20:  "memory full",
+	21:  "invalid index",
+	22:  "not found",
+	23:  "memory failure",
+	24:  "text string too long",
+	25:  "invalid characters in text string",
+	26:  "dial string too long",
+	27:  "invalid characters in dial string",
+	30:  "no network service",
+	31:  "network timeout",
+	32:  "network not allowed, emergency calls only",
+	100: "unknown",
+	103: "illegal message",
+	106: "illegal ME",
+	107: "GPRS services not allowed",
+	111: "PLMN selection not allowed",
+	112: "network service not supported",
+	132: "service option not supported",
+	133: "requested service option not subscribed",
+	134: "service option temporarily out of order",
+	148: "unspecified GPRS error",
+	149: "PDP authentication failure",
+	150: "invalid mobile class",
+}
+
+// cmsErrorStrings covers the 3GPP TS 27.005 message service failure
+// error values most commonly seen on the SIM7600.
+var cmsErrorStrings = map[int]string{
+	300: "ME failure",
+	301: "SMS service of ME reserved",
+	302: "operation not allowed",
+	303: "operation not supported",
+	304: "invalid PDU mode parameter",
+	305: "invalid text mode parameter",
+	310: "SIM not inserted",
+	311: "SIM PIN required",
+	312: "PH-SIM PIN required",
+	313: "SIM failure",
+	314: "SIM busy",
+	315: "SIM wrong",
+	316: "SIM PUK required",
+	317: "SIM PIN2 required",
+	318: "SIM PUK2 required",
+	320: "memory failure",
+	321: "invalid memory index",
+	322: "memory full",
+	330: "SMSC address unknown",
+	331: "no network service",
+	332: "network timeout",
+	340: "no +CNMA acknowledgement expected",
+	500: "unknown error",
+}
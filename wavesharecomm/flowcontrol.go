@@ -0,0 +1,123 @@
+package wavesharecomm
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlowController paces the payload of prompt-based commands (+CMGS,
+// +CIPSEND, +CFTRANTX, ...) instead of blasting the whole payload at once,
+// which can overrun the SIM7600's input buffer at 115200 baud.
+type FlowController interface {
+	// NextWindow returns how many of the `remaining` unsent bytes to send
+	// in the next write.
+	NextWindow(remaining int) int
+
+	// Ack inspects the response lines returned after a window was sent
+	// and reports whether the caller should pause before sending the
+	// next one (e.g. the modem confirmed fewer bytes than requested).
+	Ack(response [][]byte) (pause bool)
+}
+
+// WritePolicy configures how a Modem paces a prompt-based command's
+// payload via WritePaced.
+type WritePolicy struct {
+	Controller FlowController
+	PauseDelay time.Duration
+}
+
+// DefaultWritePolicy caps writes at the SIM7600's ~1460-byte per-send TCP
+// limit and pauses briefly whenever a +CIPSEND confirmation reports a
+// partial send.
+func DefaultWritePolicy() WritePolicy {
+	return WritePolicy{
+		Controller: &SIM7600FlowController{WindowSize: 1460},
+		PauseDelay: 200 * time.Millisecond,
+	}
+}
+
+// SIM7600FlowController is the default FlowController: fixed-size
+// windows, with back-pressure driven by the "+CIPSEND: <link>,<reqSend>,
+// <cnfSend>" confirmation the SIM7600 emits when its outbound TCP buffer
+// can't yet accept everything requested.
+type SIM7600FlowController struct {
+	// WindowSize is the maximum number of bytes sent per write. Values
+	// <= 0 disable chunking (the whole payload is sent in one window).
+	WindowSize int
+}
+
+func (f *SIM7600FlowController) NextWindow(remaining int) int {
+	if f.WindowSize <= 0 || remaining < f.WindowSize {
+		return remaining
+	}
+	return f.WindowSize
+}
+
+func (f *SIM7600FlowController) Ack(response [][]byte) bool {
+	for _, line := range response {
+		if !bytes.HasPrefix(line, []byte("+CIPSEND:")) {
+			continue
+		}
+		fields := strings.Split(string(bytes.TrimSpace(line[len("+CIPSEND:"):])), ",")
+		if len(fields) < 3 {
+			continue
+		}
+		reqSend, _ := strconv.Atoi(strings.TrimSpace(fields[1]))
+		cnfSend, _ := strconv.Atoi(strings.TrimSpace(fields[2]))
+		if cnfSend < reqSend {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+[Blocking]
+
+WritePaced sends `payload` as one or more prompt-based commands, chunking
+and pacing it per the Modem's WritePolicy (see SetWritePolicy). `cmdFormat`
+is called with each chunk's length and must return the AT command to send
+for it, e.g. for +CIPSEND:
+
+	m.WritePaced(func(n int) string { return fmt.Sprintf("+CIPSEND=%d,%d", linkID, n) }, data, timeout)
+*/
+func (m *Modem) WritePaced(cmdFormat func(chunkLen int) string, payload []byte, timeoutDuration time.Duration) error {
+	remaining := payload
+	for len(remaining) > 0 {
+		n := m.writePolicy.Controller.NextWindow(len(remaining))
+		if n <= 0 || n > len(remaining) {
+			n = len(remaining)
+		}
+		chunk := remaining[:n]
+
+		lines, ok, err := m.ExecutePromptCommand(cmdFormat(n), chunk, timeoutDuration)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &writeRejectedError{cmd: cmdFormat(n)}
+		}
+		remaining = remaining[n:]
+
+		if len(remaining) > 0 && m.writePolicy.Controller.Ack(lines) {
+			time.Sleep(m.writePolicy.PauseDelay)
+		}
+	}
+	return nil
+}
+
+type writeRejectedError struct {
+	cmd string
+}
+
+func (e *writeRejectedError) Error() string {
+	return "modem rejected paced write command " + e.cmd
+}
+
+// SetWritePolicy replaces the Modem's WritePolicy, which governs how
+// WritePaced chunks and paces prompt-based command payloads.
+func (m *Modem) SetWritePolicy(policy WritePolicy) {
+	m.writePolicy = policy
+}
@@ -0,0 +1,541 @@
+package wavesharecomm
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// URC is an unsolicited result code emitted by the modem outside of a
+// request/response exchange, e.g. "+CREG: 1", "RING", or a "+CMT:" header
+// paired with its SMS body line.
+type URC struct {
+	Prefix string
+	Lines  [][]byte
+}
+
+// twoLineUrcPrefixes lists the URC prefixes that are followed by a second
+// line of payload on the wire (a header line and a body line), such as
+// "+CMT:" followed by the PDU/text of the message.
+var twoLineUrcPrefixes = map[string]bool{
+	"+CMT:": true,
+}
+
+// standaloneURCWords lists the URCs that carry no "+" prefix at all, so
+// they can still be told apart from a pending command's bare-text
+// response (e.g. the digit line AT+CIMI/AT+CGSN reply with).
+var standaloneURCWords = map[string]bool{
+	"RING":        true,
+	"NO CARRIER":  true,
+	"NO ANSWER":   true,
+	"NO DIALTONE": true,
+	"BUSY":        true,
+}
+
+// commandResponsePrefix derives the "+NAME:" prefix a command's response
+// line(s) are expected to carry from the command text itself, e.g. "+CSQ:"
+// from "+CSQ", "+COPS:" from "+COPS?", "+CIPRXGET:" from
+// "+CIPRXGET=2,0,1024". It is used to tell a pending command's own
+// response apart from an unrelated URC arriving while that command is in
+// flight.
+func commandResponsePrefix(cmd string) string {
+	name := strings.TrimPrefix(cmd, "+")
+	for i, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			name = name[:i]
+			break
+		}
+	}
+	if name == "" {
+		return ""
+	}
+	return "+" + name + ":"
+}
+
+// lineBelongsToPending reports whether `line`, read while a command
+// expecting `expectedPrefix` is in flight, is part of that command's
+// response rather than an unrelated URC that happened to arrive in
+// between.
+func lineBelongsToPending(line []byte, expectedPrefix string) bool {
+	if expectedPrefix != "" && bytes.HasPrefix(line, []byte(expectedPrefix)) {
+		return true
+	}
+	if len(line) > 0 && line[0] == '+' {
+		return false
+	}
+	// Bare-text responses (AT+CIMI/AT+CGSN digits, and similar) carry no
+	// "+" prefix; only the handful of standalone URCs that also have none
+	// should be excluded.
+	return !standaloneURCWords[string(bytes.TrimSpace(line))]
+}
+
+// commandRequest is a single caller's request to run an AT command, handed
+// to the Modem's read/dispatch loop over reqCh.
+type commandRequest struct {
+	cmd     string
+	timeout time.Duration
+	respCh  chan commandResult
+
+	// promptPayload, if non-nil, is written (followed by Ctrl-Z) once the
+	// modem's "> " data prompt is seen, for commands such as +CMGS and
+	// +CIPSEND whose payload does not follow the normal AT command line.
+	promptPayload []byte
+
+	// rawDataLength, if set, is called with each response line belonging
+	// to this command. A non-zero return means that line is immediately
+	// followed by that many raw, unframed bytes (e.g. +CIPRXGET's binary
+	// payload) which must be read verbatim rather than split into lines.
+	rawDataLength func(line []byte) int
+}
+
+type commandResult struct {
+	lines [][]byte
+	ok    bool
+	err   error
+}
+
+// Modem owns a serial port exclusively: one goroutine reads every line that
+// comes off the wire and either correlates it with the in-flight AT command
+// or, if no command is in flight or the line is not part of that command's
+// response, publishes it to subscribers as a URC.
+// This lets a single port be shared safely by code issuing commands and
+// code that just wants to observe unsolicited modem events.
+type Modem struct {
+	port   io.ReadWriteCloser
+	reader *bufio.Reader
+
+	reqCh chan *commandRequest
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+
+	subMu sync.Mutex
+	subs  map[string][]chan URC
+
+	writePolicy WritePolicy
+}
+
+// NewModem starts the background read/dispatch loop over `port` and returns
+// the Modem that owns it. Callers should use the returned Modem's
+// ExecuteCommand and Subscribe instead of reading/writing `port` directly.
+func NewModem(port io.ReadWriteCloser) *Modem {
+	m := &Modem{
+		port:        port,
+		reader:      bufio.NewReader(port),
+		reqCh:       make(chan *commandRequest),
+		closeCh:     make(chan struct{}),
+		subs:        make(map[string][]chan URC),
+		writePolicy: DefaultWritePolicy(),
+	}
+	go m.run()
+	return m
+}
+
+// Subscribe returns a channel of URCs whose prefix equals `prefix`: the
+// first line up to and including its first ':' (e.g. "+CREG:"), or the
+// whole line if it has no ':' (e.g. "RING"). A partial prefix such as
+// "+CRE" matches nothing. The channel is buffered; a slow subscriber can
+// drop events rather than stalling the read loop. The channel is closed
+// when the Modem is closed.
+func (m *Modem) Subscribe(prefix string) <-chan URC {
+	ch := make(chan URC, 16)
+	m.subMu.Lock()
+	m.subs[prefix] = append(m.subs[prefix], ch)
+	m.subMu.Unlock()
+	return ch
+}
+
+// Close stops the read/dispatch loop, closes every subscriber channel, and
+// closes the underlying port.
+func (m *Modem) Close() error {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+	return m.port.Close()
+}
+
+/*
+[Blocking]
+
+ExecuteCommand sends `cmd` (formatted as "AT"+cmd) and waits for the
+terminal OK/ERROR/+CME ERROR/+CMS ERROR line, serializing against any other
+concurrent callers sharing this Modem.
+
+Times out if the given `timeoutDuration` elapses before the terminal line
+arrives.
+*/
+func (m *Modem) ExecuteCommand(cmd string, timeoutDuration time.Duration) (response []byte, ok bool, err error) {
+	lines, ok, err := m.executeRaw(cmd, timeoutDuration)
+	return bytes.Join(lines, []byte("\n")), ok, err
+}
+
+/*
+[Blocking]
+
+RunCommand sends `cmd.Format()` and, once the terminal line arrives, hands
+the collected response lines to `cmd.Parse`. This is the typed counterpart
+to ExecuteCommand for the built-in commands in commands.go (and any
+caller-defined Command).
+*/
+func (m *Modem) RunCommand(cmd Command, timeoutDuration time.Duration) (any, error) {
+	lines, ok, err := m.executeRaw(cmd.Format(), timeoutDuration)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("command AT%s returned ERROR", cmd.Format())
+	}
+	return cmd.Parse(lines)
+}
+
+// executeRaw is the shared implementation behind ExecuteCommand and
+// RunCommand: it sends `cmd` and returns every response line collected
+// before the terminal OK/ERROR/+CME ERROR/+CMS ERROR line.
+func (m *Modem) executeRaw(cmd string, timeoutDuration time.Duration) (lines [][]byte, ok bool, err error) {
+	return m.executeRequest(&commandRequest{cmd: cmd, timeout: timeoutDuration})
+}
+
+/*
+[Blocking]
+
+ExecutePromptCommand sends `cmd`, waits for the modem's "> " data prompt,
+then writes `payload` followed by Ctrl-Z (0x1A) before waiting for the
+terminal OK/ERROR line as usual. This is the AT command shape used by
++CMGS (PDU SMS send) and +CIPSEND, where the payload itself is not part of
+the initial command line.
+*/
+func (m *Modem) ExecutePromptCommand(cmd string, payload []byte, timeoutDuration time.Duration) (lines [][]byte, ok bool, err error) {
+	return m.executeRequest(&commandRequest{cmd: cmd, timeout: timeoutDuration, promptPayload: payload})
+}
+
+/*
+[Blocking]
+
+ExecuteBinaryCommand sends `cmd` for a command whose response includes a
+raw, unframed binary payload following one of its lines (as +CIPRXGET=2
+does for received TCP/UDP data). `rawDataLength` is called with each
+response line as it arrives and must return how many raw bytes immediately
+follow it (0 if none); that many bytes are read verbatim, bypassing the
+usual line/URC splitting that would otherwise corrupt any CR/LF bytes in
+the payload, and appended to the returned lines as their own element.
+*/
+func (m *Modem) ExecuteBinaryCommand(cmd string, rawDataLength func(line []byte) int, timeoutDuration time.Duration) (lines [][]byte, ok bool, err error) {
+	return m.executeRequest(&commandRequest{cmd: cmd, timeout: timeoutDuration, rawDataLength: rawDataLength})
+}
+
+func (m *Modem) executeRequest(req *commandRequest) (lines [][]byte, ok bool, err error) {
+	req.respCh = make(chan commandResult, 1)
+
+	select {
+	case m.reqCh <- req:
+	case <-m.closeCh:
+		return nil, false, errors.New("modem is closed")
+	}
+
+	select {
+	case res := <-req.respCh:
+		return res.lines, res.ok, res.err
+	case <-m.closeCh:
+		return nil, false, errors.New("modem is closed")
+	}
+}
+
+// isTerminalLine reports whether `line` ends an AT command/response
+// exchange.
+func isTerminalLine(line []byte) bool {
+	trimmed := bytes.TrimSpace(line)
+	if bytes.Equal(trimmed, OkResponseOk) {
+		return true
+	}
+	if bytes.HasPrefix(trimmed, []byte("ERROR")) {
+		return true
+	}
+	if bytes.HasPrefix(trimmed, []byte("+CME ERROR")) {
+		return true
+	}
+	if bytes.HasPrefix(trimmed, []byte("+CMS ERROR")) {
+		return true
+	}
+	return false
+}
+
+// lineEvent is one line read off the port. readLines blocks after sending
+// it until run() replies on its directives channel, so the two goroutines never
+// disagree about whether the bytes immediately following the line are
+// more lines or a raw binary payload.
+type lineEvent struct {
+	line       []byte
+	directives chan<- lineDirective
+}
+
+// lineDirective is run()'s reply to a lineEvent: if rawDataLength is
+// non-zero, readLines reads that many raw bytes next and delivers them on
+// rawDataCh before resuming normal line reading.
+type lineDirective struct {
+	rawDataLength int
+}
+
+// run is the Modem's single reader goroutine. It owns `m.port` for its
+// entire lifetime: no other code may read from or write to the port once
+// the Modem has started.
+func (m *Modem) run() {
+	defer m.closeSubscribers()
+
+	lineCh := make(chan lineEvent)
+	promptCh := make(chan struct{})
+	rawDataCh := make(chan []byte)
+	errCh := make(chan error, 1)
+	go m.readLines(lineCh, promptCh, rawDataCh, errCh)
+
+	var pending *commandRequest
+	var collected [][]byte
+	var expectedPrefix string
+
+	var urcPrefix string
+	var urcFirstLine []byte
+
+	// A single Timer is reused for every command's timeout instead of
+	// allocating a fresh one per ExecuteCommand call, which would leak
+	// (run()'s select loop never returns until Close, so a per-call
+	// `defer timer.Stop()` would never fire).
+	timer := time.NewTimer(time.Hour)
+	if !timer.Stop() {
+		<-timer.C
+	}
+	defer timer.Stop()
+
+	stopTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+	}
+
+	for {
+		// Only accept a new request once the previous one's terminal line
+		// has arrived: reqCh is nil'd out locally while a command is
+		// pending so a second concurrent caller genuinely queues behind it
+		// instead of clobbering pending/collected/expectedPrefix.
+		reqCh := m.reqCh
+		if pending != nil {
+			reqCh = nil
+		}
+
+		select {
+		case <-m.closeCh:
+			return
+
+		case req := <-reqCh:
+			if err := WriteCommand(m.port, req.cmd); err != nil {
+				req.respCh <- commandResult{err: fmt.Errorf("error writing command to port: %w", err)}
+				continue
+			}
+			pending = req
+			collected = nil
+			expectedPrefix = commandResponsePrefix(req.cmd)
+			timer.Reset(req.timeout)
+
+		case <-promptCh:
+			if pending == nil || pending.promptPayload == nil {
+				continue
+			}
+			// Copy before appending Ctrl-Z: promptPayload may be a sub-slice
+			// of a caller's larger buffer (e.g. one WritePaced window).
+			payload := append(append([]byte{}, pending.promptPayload...), 0x1A)
+			if _, err := m.port.Write(payload); err != nil {
+				stopTimer()
+				pending.respCh <- commandResult{err: fmt.Errorf("error writing prompt payload to port: %w", err)}
+				pending = nil
+			}
+
+		case ev, chOk := <-lineCh:
+			if !chOk {
+				return
+			}
+			line := ev.line
+			if len(bytes.TrimSpace(line)) == 0 {
+				ev.directives <- lineDirective{}
+				continue
+			}
+
+			if pending == nil {
+				urcPrefix, urcFirstLine = m.dispatchURC(line, urcPrefix, urcFirstLine)
+				ev.directives <- lineDirective{}
+				continue
+			}
+
+			if isTerminalLine(line) {
+				trimmed := bytes.TrimSpace(line)
+				stopTimer()
+				pending.respCh <- commandResult{
+					lines: collected,
+					ok:    bytes.Equal(trimmed, OkResponseOk),
+					err:   parseATError(trimmed),
+				}
+				pending = nil
+				collected = nil
+				ev.directives <- lineDirective{}
+				continue
+			}
+
+			if urcPrefix != "" || !lineBelongsToPending(line, expectedPrefix) {
+				// A URC (or the body line of a two-line URC whose header
+				// was just seen) arrived while a command was in flight;
+				// route it to subscribers instead of silently folding it
+				// into the pending command's response.
+				urcPrefix, urcFirstLine = m.dispatchURC(line, urcPrefix, urcFirstLine)
+				ev.directives <- lineDirective{}
+				continue
+			}
+			collected = append(collected, line)
+
+			rawLen := 0
+			if pending.rawDataLength != nil {
+				rawLen = pending.rawDataLength(line)
+			}
+			ev.directives <- lineDirective{rawDataLength: rawLen}
+			if rawLen > 0 {
+				select {
+				case raw := <-rawDataCh:
+					collected = append(collected, raw)
+				case err := <-errCh:
+					log.Printf("error reading from modem port: %v", err)
+					stopTimer()
+					pending.respCh <- commandResult{err: err}
+					pending = nil
+					return
+				case <-m.closeCh:
+					return
+				}
+			}
+
+		case err := <-errCh:
+			log.Printf("error reading from modem port: %v", err)
+			if pending != nil {
+				stopTimer()
+				pending.respCh <- commandResult{err: err}
+				pending = nil
+			}
+			return
+
+		case <-timer.C:
+			if pending != nil {
+				pending.respCh <- commandResult{err: errors.New("operation timed out")}
+				pending = nil
+			}
+		}
+	}
+}
+
+// dispatchURC handles a single line that is not part of a pending command's
+// response (including every line read while no command is in flight). It
+// coalesces known two-line URCs (a header line such as "+CMT:" followed by
+// its body line) into one URC, and returns the in-progress prefix/first
+// line so the caller can carry that state to the next line.
+func (m *Modem) dispatchURC(line []byte, pendingPrefix string, pendingFirstLine []byte) (string, []byte) {
+	if pendingPrefix != "" {
+		m.publishURC(URC{Prefix: pendingPrefix, Lines: [][]byte{pendingFirstLine, line}})
+		return "", nil
+	}
+
+	for prefix := range twoLineUrcPrefixes {
+		if bytes.HasPrefix(line, []byte(prefix)) {
+			return prefix, line
+		}
+	}
+
+	prefix := string(line)
+	if idx := bytes.IndexByte(line, ':'); idx >= 0 {
+		prefix = string(line[:idx+1])
+	}
+	m.publishURC(URC{Prefix: prefix, Lines: [][]byte{line}})
+	return "", nil
+}
+
+func (m *Modem) publishURC(u URC) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for prefix, chans := range m.subs {
+		if u.Prefix != prefix {
+			continue
+		}
+		for _, ch := range chans {
+			select {
+			case ch <- u:
+			default:
+				log.Printf("dropping URC for slow subscriber on prefix %q", prefix)
+			}
+		}
+	}
+}
+
+func (m *Modem) closeSubscribers() {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, chans := range m.subs {
+		for _, ch := range chans {
+			close(ch)
+		}
+	}
+}
+
+// readLines continuously reads off the port and pushes complete lines onto
+// lineCh until the port errors, at which point the error is pushed onto
+// errCh and the goroutine exits.
+//
+// It also recognizes the modem's "> " data prompt (used by +CMGS and
+// +CIPSEND) as a special case: unlike every other response, the modem
+// never terminates it with a CRLF, so it cannot be read with ReadLine and
+// is instead signaled on promptCh.
+func (m *Modem) readLines(lineCh chan<- lineEvent, promptCh chan<- struct{}, rawDataCh chan<- []byte, errCh chan<- error) {
+	var cur []byte
+	for {
+		b, err := m.reader.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				continue
+			}
+			errCh <- err
+			return
+		}
+
+		if b == '>' && len(cur) == 0 {
+			if next, err := m.reader.ReadByte(); err == nil {
+				if next == ' ' {
+					promptCh <- struct{}{}
+					continue
+				}
+				cur = append(cur, b, next)
+				continue
+			}
+		}
+
+		if b == '\n' {
+			line := bytes.TrimRight(cur, "\r")
+			cur = nil
+
+			directives := make(chan lineDirective, 1)
+			lineCh <- lineEvent{line: line, directives: directives}
+			directive := <-directives
+			if directive.rawDataLength > 0 {
+				raw := make([]byte, directive.rawDataLength)
+				if _, err := io.ReadFull(m.reader, raw); err != nil {
+					errCh <- err
+					return
+				}
+				rawDataCh <- raw
+			}
+			continue
+		}
+		cur = append(cur, b)
+	}
+}
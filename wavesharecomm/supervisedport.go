@@ -0,0 +1,250 @@
+package wavesharecomm
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// PortStatus describes the current connection state of a SupervisedPort.
+type PortStatus int
+
+const (
+	PortConnected PortStatus = iota
+	PortReconnecting
+	PortFailed
+)
+
+func (s PortStatus) String() string {
+	switch s {
+	case PortConnected:
+		return "connected"
+	case PortReconnecting:
+		return "reconnecting"
+	case PortFailed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+const (
+	reconnectInitialBackoff = 500 * time.Millisecond
+	reconnectMaxBackoff     = 30 * time.Second
+)
+
+/*
+SupervisedPort wraps a serial port opened at `portPath` and keeps it alive
+across USB resets, modem reboots, and read/write errors: on any error it
+closes the port, backs off with jitter, reopens via `openFn`, and re-runs
+`reinit` before resuming.
+
+This is intended for long-running daemons where the very common
+"ttyUSB2 disappears when the modem resets" failure mode should not be
+fatal.
+*/
+type SupervisedPort struct {
+	portPath string
+	openFn   func(portPath string) (io.ReadWriteCloser, error)
+	reinit   func(io.ReadWriteCloser) error
+
+	mu         sync.Mutex
+	port       io.ReadWriteCloser
+	status     PortStatus
+	generation int
+
+	// reconnectMu serializes reconnect attempts. Read and Write run on
+	// separate goroutines and can observe the same port failure at the
+	// same time; without this, both would race into independent open()
+	// calls, each liable to clobber the other's freshly opened port.
+	reconnectMu sync.Mutex
+
+	stateCh chan PortStatus
+
+	closeOnce sync.Once
+	closeCh   chan struct{}
+}
+
+// NewSupervisedPort opens `portPath` via `openFn` and returns a
+// SupervisedPort that keeps it alive. `reinit` is run once immediately
+// after every (re)open, including the first one, to restore modem state
+// such as `ATE0` or URC registration; pass nil if no re-initialization is
+// needed.
+func NewSupervisedPort(portPath string, openFn func(portPath string) (io.ReadWriteCloser, error), reinit func(io.ReadWriteCloser) error) (*SupervisedPort, error) {
+	sp := &SupervisedPort{
+		portPath: portPath,
+		openFn:   openFn,
+		reinit:   reinit,
+		stateCh:  make(chan PortStatus, 1),
+		closeCh:  make(chan struct{}),
+	}
+	if err := sp.open(); err != nil {
+		return nil, fmt.Errorf("error opening port %s: %w", portPath, err)
+	}
+	return sp, nil
+}
+
+// Status returns the SupervisedPort's current connection state.
+func (sp *SupervisedPort) Status() PortStatus {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	return sp.status
+}
+
+// StateChanged returns a channel that receives the SupervisedPort's status
+// whenever it changes. The channel holds one value; a change that occurs
+// while the previous one is still unread is not delivered (Status still
+// reports it).
+func (sp *SupervisedPort) StateChanged() <-chan PortStatus {
+	return sp.stateCh
+}
+
+// Close stops any in-progress reconnect attempt and closes the underlying
+// port.
+func (sp *SupervisedPort) Close() error {
+	var err error
+	sp.closeOnce.Do(func() {
+		close(sp.closeCh)
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+		if sp.port != nil {
+			err = sp.port.Close()
+		}
+	})
+	return err
+}
+
+// Read implements io.Reader, reconnecting and retrying on error.
+func (sp *SupervisedPort) Read(p []byte) (int, error) {
+	for {
+		sp.mu.Lock()
+		port := sp.port
+		generation := sp.generation
+		sp.mu.Unlock()
+
+		n, err := port.Read(p)
+		if err == nil {
+			return n, nil
+		}
+		if !sp.reconnect(generation) {
+			return n, err
+		}
+	}
+}
+
+// Write implements io.Writer, reconnecting and retrying on error.
+func (sp *SupervisedPort) Write(p []byte) (int, error) {
+	for {
+		sp.mu.Lock()
+		port := sp.port
+		generation := sp.generation
+		sp.mu.Unlock()
+
+		n, err := port.Write(p)
+		if err == nil {
+			return n, nil
+		}
+		if !sp.reconnect(generation) {
+			return n, err
+		}
+	}
+}
+
+// reconnect handles a Read/Write error: it closes the failed port, backs
+// off, and reopens. It returns true if the caller should retry its
+// operation against the newly opened port, or false if the SupervisedPort
+// has been closed and the caller should give up.
+//
+// `generation` is the generation of the port the caller was using when it
+// failed, as captured alongside that port under sp.mu. Read and Write run
+// on separate goroutines and can both observe a failure on the same port
+// generation at once; reconnectMu serializes the actual reopen so only one
+// of them does the work. The one that loses the race blocks on
+// reconnectMu, then finds sp.generation already past the one it failed
+// on and returns immediately instead of reopening a second time.
+func (sp *SupervisedPort) reconnect(generation int) bool {
+	select {
+	case <-sp.closeCh:
+		return false
+	default:
+	}
+
+	sp.reconnectMu.Lock()
+	defer sp.reconnectMu.Unlock()
+
+	sp.mu.Lock()
+	current := sp.generation
+	sp.mu.Unlock()
+	if current != generation {
+		return true
+	}
+
+	sp.setStatus(PortReconnecting)
+
+	sp.mu.Lock()
+	if sp.port != nil {
+		sp.port.Close()
+	}
+	sp.mu.Unlock()
+
+	backoff := reconnectInitialBackoff
+	for {
+		select {
+		case <-sp.closeCh:
+			return false
+		case <-time.After(jitter(backoff)):
+		}
+
+		if err := sp.open(); err == nil {
+			return true
+		}
+
+		backoff *= 2
+		if backoff > reconnectMaxBackoff {
+			backoff = reconnectMaxBackoff
+		}
+	}
+}
+
+// open opens the port and runs `reinit`, storing the result and moving to
+// PortConnected only on full success.
+func (sp *SupervisedPort) open() error {
+	port, err := sp.openFn(sp.portPath)
+	if err != nil {
+		return err
+	}
+	if sp.reinit != nil {
+		if err := sp.reinit(port); err != nil {
+			port.Close()
+			return fmt.Errorf("error re-initializing port: %w", err)
+		}
+	}
+
+	sp.mu.Lock()
+	sp.port = port
+	sp.generation++
+	sp.mu.Unlock()
+
+	sp.setStatus(PortConnected)
+	return nil
+}
+
+func (sp *SupervisedPort) setStatus(status PortStatus) {
+	sp.mu.Lock()
+	sp.status = status
+	sp.mu.Unlock()
+
+	select {
+	case sp.stateCh <- status:
+	default:
+	}
+}
+
+// jitter returns a duration within +/-25% of `d`, to avoid synchronized
+// reconnect storms across multiple supervised ports.
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * 0.25
+	return d + time.Duration((rand.Float64()*2-1)*delta)
+}